@@ -0,0 +1,56 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package etcd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k0sproject/k0s/pkg/config"
+	"github.com/k0sproject/k0s/pkg/etcd"
+)
+
+func etcdDefragmentCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "defragment",
+		Short: "Defragments the local etcd member's backend database",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := CmdOpts(config.GetCmdOpts())
+			cfg, err := config.GetNodeConfig(c.CfgFile, c.K0sVars)
+			if err != nil {
+				return err
+			}
+			c.ClusterConfig = cfg
+
+			ctx := context.Background()
+			etcdClient, err := etcd.NewClient(c.K0sVars.CertRootDir, c.K0sVars.EtcdCertDir, c.ClusterConfig.Spec.Storage.Etcd)
+			if err != nil {
+				return fmt.Errorf("can't defragment etcd: %v", err)
+			}
+			if err := etcdClient.Defragment(ctx); err != nil {
+				return fmt.Errorf("can't defragment etcd: %v", err)
+			}
+			return printOutput(cmd, output, map[string]interface{}{"defragmented": true}, nil)
+		},
+	}
+	cmd.Flags().AddFlagSet(config.FileInputFlag())
+	cmd.Flags().AddFlagSet(outputFlagSet(&output))
+	cmd.PersistentFlags().AddFlagSet(config.GetPersistentFlagSet())
+	return cmd
+}
@@ -17,9 +17,7 @@ package etcd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 
@@ -28,6 +26,7 @@ import (
 )
 
 func etcdListCmd() *cobra.Command {
+	var output string
 	cmd := &cobra.Command{
 		Use:   "member-list",
 		Short: "Returns etcd cluster members list",
@@ -48,10 +47,11 @@ func etcdListCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("can't list etcd cluster members: %v", err)
 			}
-			return json.NewEncoder(os.Stdout).Encode(map[string]interface{}{"members": members})
+			return printOutput(cmd, output, map[string]interface{}{"members": members}, printMembersTable)
 		},
 	}
 	cmd.Flags().AddFlagSet(config.FileInputFlag())
+	cmd.Flags().AddFlagSet(outputFlagSet(&output))
 	cmd.PersistentFlags().AddFlagSet(config.GetPersistentFlagSet())
 	return cmd
 }
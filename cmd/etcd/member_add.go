@@ -0,0 +1,75 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k0sproject/k0s/pkg/config"
+	"github.com/k0sproject/k0s/pkg/etcd"
+)
+
+func etcdMemberAddCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "member-add <peerURL>",
+		Short: "Adds a new member to the etcd cluster",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := CmdOpts(config.GetCmdOpts())
+			cfg, err := config.GetNodeConfig(c.CfgFile, c.K0sVars)
+			if err != nil {
+				return err
+			}
+			c.ClusterConfig = cfg
+
+			ctx := context.Background()
+			etcdClient, err := etcd.NewClient(c.K0sVars.CertRootDir, c.K0sVars.EtcdCertDir, c.ClusterConfig.Spec.Storage.Etcd)
+			if err != nil {
+				return fmt.Errorf("can't add etcd cluster member: %v", err)
+			}
+			members, err := etcdClient.AddMember(ctx, args[0])
+			if err != nil {
+				return fmt.Errorf("can't add etcd cluster member: %v", err)
+			}
+			return printOutput(cmd, output, map[string]interface{}{"members": members}, printMembersTable)
+		},
+	}
+	cmd.Flags().AddFlagSet(config.FileInputFlag())
+	cmd.Flags().AddFlagSet(outputFlagSet(&output))
+	cmd.PersistentFlags().AddFlagSet(config.GetPersistentFlagSet())
+	return cmd
+}
+
+func printMembersTable(w io.Writer, data interface{}) error {
+	payload, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("unexpected payload for table output")
+	}
+	members, ok := payload["members"].(map[string]uint64)
+	if !ok {
+		return fmt.Errorf("unexpected members payload for table output")
+	}
+	fmt.Fprintln(w, "NAME\tID")
+	for name, id := range members {
+		fmt.Fprintf(w, "%s\t%d\n", name, id)
+	}
+	return nil
+}
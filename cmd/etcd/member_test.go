@@ -0,0 +1,46 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package etcd
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintMembersTable(t *testing.T) {
+	t.Run("prints member IDs in decimal, matching member-remove's expected input", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := printMembersTable(&buf, map[string]interface{}{
+			"members": map[string]uint64{"member-a": 10276657743932975437},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "member-a\t10276657743932975437\n")
+
+		id, err := strconv.ParseUint("10276657743932975437", 10, 64)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(10276657743932975437), id)
+	})
+
+	t.Run("rejects an unexpected payload shape", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := printMembersTable(&buf, "not a map")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package etcd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// outputFormat is shared by the etcd subcommands that print structured data
+type outputFormat string
+
+const (
+	outputFormatJSON  outputFormat = "json"
+	outputFormatYAML  outputFormat = "yaml"
+	outputFormatTable outputFormat = "table"
+)
+
+// outputFlagSet returns the `-o`/`--output` flag shared by the etcd subcommands
+func outputFlagSet(format *string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("output", pflag.ContinueOnError)
+	flags.StringVarP(format, "output", "o", string(outputFormatJSON), "Output format (json|yaml|table)")
+	return flags
+}
+
+// printOutput renders data as JSON (default) or YAML, or delegates to
+// printTable for the table format
+func printOutput(cmd *cobra.Command, format string, data interface{}, printTable func(io.Writer, interface{}) error) error {
+	switch outputFormat(format) {
+	case outputFormatYAML:
+		out, err := yaml.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("can't marshal output as yaml: %v", err)
+		}
+		_, err = cmd.OutOrStdout().Write(out)
+		return err
+	case outputFormatTable:
+		if printTable == nil {
+			return fmt.Errorf("table output is not supported for this command")
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		if err := printTable(w, data); err != nil {
+			return err
+		}
+		return w.Flush()
+	default:
+		return json.NewEncoder(cmd.OutOrStdout()).Encode(data)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package etcd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k0sproject/k0s/pkg/component/controller"
+	"github.com/k0sproject/k0s/pkg/config"
+	"github.com/k0sproject/k0s/pkg/etcd"
+)
+
+func etcdSnapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Manages etcd snapshots",
+	}
+	cmd.AddCommand(etcdSnapshotSaveCmd())
+	cmd.AddCommand(etcdSnapshotRestoreCmd())
+	return cmd
+}
+
+func etcdSnapshotSaveCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "save <path>",
+		Short: "Takes a point-in-time snapshot of the etcd backend database",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := CmdOpts(config.GetCmdOpts())
+			cfg, err := config.GetNodeConfig(c.CfgFile, c.K0sVars)
+			if err != nil {
+				return err
+			}
+			c.ClusterConfig = cfg
+
+			ctx := context.Background()
+			etcdClient, err := etcd.NewClient(c.K0sVars.CertRootDir, c.K0sVars.EtcdCertDir, c.ClusterConfig.Spec.Storage.Etcd)
+			if err != nil {
+				return fmt.Errorf("can't save etcd snapshot: %v", err)
+			}
+			if err := etcdClient.Snapshot(ctx, args[0]); err != nil {
+				return fmt.Errorf("can't save etcd snapshot: %v", err)
+			}
+			return printOutput(cmd, output, map[string]interface{}{"path": args[0]}, nil)
+		},
+	}
+	cmd.Flags().AddFlagSet(config.FileInputFlag())
+	cmd.Flags().AddFlagSet(outputFlagSet(&output))
+	cmd.PersistentFlags().AddFlagSet(config.GetPersistentFlagSet())
+	return cmd
+}
+
+func etcdSnapshotRestoreCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "restore <path>",
+		Short: "Restores the etcd data-dir from a snapshot taken with `snapshot save`",
+		Long: "Restores the etcd data-dir from a snapshot taken with `snapshot save`. " +
+			"The local etcd component is stopped for the duration of the restore and " +
+			"the cluster's peerURLs are rewritten to match this node's configuration " +
+			"before etcd is resumed.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := CmdOpts(config.GetCmdOpts())
+			cfg, err := config.GetNodeConfig(c.CfgFile, c.K0sVars)
+			if err != nil {
+				return err
+			}
+			c.ClusterConfig = cfg
+
+			etcdClient, err := etcd.NewClient(c.K0sVars.CertRootDir, c.K0sVars.EtcdCertDir, c.ClusterConfig.Spec.Storage.Etcd)
+			if err != nil {
+				return fmt.Errorf("can't restore etcd snapshot: %v", err)
+			}
+
+			storage := c.ClusterConfig.Spec.Storage.Etcd
+			if err := stopLocalEtcd(c); err != nil {
+				return fmt.Errorf("can't stop local etcd for restore: %v", err)
+			}
+
+			if err := restoreAndResume(
+				func() error {
+					return etcdClient.RestoreSnapshot(args[0], c.K0sVars.EtcdDataDir, storage.Name(), storage.PeerAddress)
+				},
+				func() error { return startLocalEtcd(c) },
+			); err != nil {
+				return err
+			}
+
+			return printOutput(cmd, output, map[string]interface{}{"restored": args[0]}, nil)
+		},
+	}
+	cmd.Flags().AddFlagSet(config.FileInputFlag())
+	cmd.Flags().AddFlagSet(outputFlagSet(&output))
+	cmd.PersistentFlags().AddFlagSet(config.GetPersistentFlagSet())
+	return cmd
+}
+
+// stopLocalEtcd stops the node's locally supervised etcd process so its
+// data-dir is safe to overwrite with a restored snapshot. It is a no-op if
+// this node isn't currently running an etcd supervisor.
+func stopLocalEtcd(c CmdOpts) error {
+	return controller.NewEtcd(c.K0sVars).Stop()
+}
+
+// startLocalEtcd resumes the node's locally supervised etcd process after a
+// snapshot restore has completed.
+func startLocalEtcd(c CmdOpts) error {
+	return controller.NewEtcd(c.K0sVars).Run(context.Background())
+}
+
+// restoreAndResume runs restore and then always runs resume, folding a
+// resume failure into the restore error so the operator sees both problems
+// instead of only whichever ran last. Split out of etcdSnapshotRestoreCmd's
+// RunE so this error-combining logic can be exercised with fakes instead of
+// a real etcd client and supervisor.
+func restoreAndResume(restore func() error, resume func() error) error {
+	restoreErr := restore()
+	if err := resume(); err != nil {
+		if restoreErr != nil {
+			return fmt.Errorf("can't restore etcd snapshot: %v (and can't resume local etcd afterwards: %v)", restoreErr, err)
+		}
+		return fmt.Errorf("can't resume local etcd after restore: %v", err)
+	}
+	if restoreErr != nil {
+		return fmt.Errorf("can't restore etcd snapshot: %v", restoreErr)
+	}
+	return nil
+}
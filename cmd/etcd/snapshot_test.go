@@ -0,0 +1,67 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package etcd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errBoom = errors.New("boom")
+
+func TestRestoreAndResume(t *testing.T) {
+	t.Run("resume always runs even when restore succeeds", func(t *testing.T) {
+		resumed := false
+		err := restoreAndResume(
+			func() error { return nil },
+			func() error { resumed = true; return nil },
+		)
+		require.NoError(t, err)
+		assert.True(t, resumed)
+	})
+
+	t.Run("restore failure surfaces once resume also succeeds", func(t *testing.T) {
+		err := restoreAndResume(
+			func() error { return errBoom },
+			func() error { return nil },
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't restore etcd snapshot: boom")
+	})
+
+	t.Run("resume failure alone is reported when restore succeeds", func(t *testing.T) {
+		err := restoreAndResume(
+			func() error { return nil },
+			func() error { return errBoom },
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't resume local etcd after restore: boom")
+	})
+
+	t.Run("resume runs even after restore fails, and both errors are folded together", func(t *testing.T) {
+		resumeErr := errors.New("supervisor down")
+		err := restoreAndResume(
+			func() error { return errBoom },
+			func() error { return resumeErr },
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't restore etcd snapshot: boom")
+		assert.Contains(t, err.Error(), "can't resume local etcd afterwards: supervisor down")
+	})
+}
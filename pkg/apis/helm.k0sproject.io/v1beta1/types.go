@@ -0,0 +1,182 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1beta1
+
+import (
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Chart defines the Chart CRD
+type Chart struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ChartSpec   `json:"spec,omitempty"`
+	Status ChartStatus `json:"status,omitempty"`
+}
+
+// ChartSpec defines the helm chart installation source and configuration
+type ChartSpec struct {
+	ChartName string `json:"chartName"`
+	Version   string `json:"version"`
+	Values    string `json:"values"`
+	Namespace string `json:"namespace"`
+	// ForceUpgrade, when true, passes `--force` down to the underlying Helm upgrade action.
+	ForceUpgrade *bool `json:"forceUpgrade,omitempty"`
+	// MaxHistory limits the number of revisions saved per release, mirroring Helm's `--history-max`.
+	MaxHistory int `json:"maxHistory,omitempty"`
+	// Rollback controls the automatic rollback/retry behaviour on a failed install or upgrade.
+	Rollback *RollbackPolicy `json:"rollback,omitempty"`
+	// ValuesFrom merges values sourced from ConfigMaps/Secrets on top of Values, in order.
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+	// Verify enables chart provenance verification before install/upgrade.
+	Verify *VerifyPolicy `json:"verify,omitempty"`
+}
+
+// VerifyPolicy enables verifying a chart's `.prov` signature against a GPG keyring.
+type VerifyPolicy struct {
+	// Enabled turns on provenance verification for this chart.
+	Enabled bool `json:"enabled,omitempty"`
+	// KeyringSecretRef names a Secret, in the Chart's namespace, holding the public keyring
+	// under its `keyring.gpg` key.
+	KeyringSecretRef string `json:"keyringSecretRef,omitempty"`
+}
+
+// VerifyEnabled reports whether provenance verification was requested.
+func (chartSpec ChartSpec) VerifyEnabled() bool {
+	return chartSpec.Verify != nil && chartSpec.Verify.Enabled
+}
+
+// ValuesReference points at a single key within a ConfigMap or Secret whose
+// value is YAML/JSON encoded and merged into a chart's values.
+type ValuesReference struct {
+	// Kind is either "ConfigMap" or "Secret".
+	Kind string `json:"kind"`
+	// Name of the ConfigMap/Secret, in the same namespace as the Chart.
+	Name string `json:"name"`
+	// Key within the ConfigMap/Secret holding the YAML/JSON encoded values.
+	Key string `json:"key"`
+	// PathPrefix, when set, nests the resolved values under this dotted path before merging.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+}
+
+// RollbackPolicy controls automatic rollback/retry on a failed install or upgrade.
+type RollbackPolicy struct {
+	// Enabled turns on automatic rollback to the last successful revision.
+	Enabled bool `json:"enabled,omitempty"`
+	// MaxRetries bounds how many failed reconciles are retried before the
+	// Chart is marked Irreconcilable. Zero means retry indefinitely.
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// Timeout bounds how long the rollback itself is allowed to take.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// CleanupOnFail deletes new resources created by the failed install/upgrade being rolled back.
+	CleanupOnFail bool `json:"cleanupOnFail,omitempty"`
+}
+
+// ForceUpgradeEnabled reports whether forceUpgrade was requested, defaulting to false.
+func (chartSpec ChartSpec) ForceUpgradeEnabled() bool {
+	return chartSpec.ForceUpgrade != nil && *chartSpec.ForceUpgrade
+}
+
+// YamlValues returns the values as map
+func (chartSpec ChartSpec) YamlValues() map[string]interface{} {
+	values := map[string]interface{}{}
+	_ = yaml.Unmarshal([]byte(chartSpec.Values), &values)
+	return values
+}
+
+// ChartStatus defines the observed state of a Chart release
+type ChartStatus struct {
+	ReleaseName string `json:"releaseName"`
+	Version     string `json:"version"`
+	AppVersion  string `json:"appVersion"`
+	Revision    int64  `json:"revision"`
+	Namespace   string `json:"namespace"`
+	Updated     string `json:"updated,omitempty"`
+	// AppliedRevision is the chart Generation last reconciled, successfully or not.
+	AppliedRevision int64 `json:"appliedRevision,omitempty"`
+	// Conditions holds the latest observed conditions, see the ConditionType* constants.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// History tracks the successful revisions of the release, most recent last.
+	History []ChartRevision `json:"history,omitempty"`
+	// RetryCount counts consecutive failed reconciles since the last success.
+	RetryCount int `json:"retryCount,omitempty"`
+}
+
+// ChartRevision records a single successfully deployed revision of a release.
+type ChartRevision struct {
+	Revision   int64  `json:"revision"`
+	Version    string `json:"version"`
+	AppVersion string `json:"appVersion"`
+	Updated    string `json:"updated"`
+}
+
+// well-known ChartStatus condition types
+const (
+	ConditionTypeDeployed           = "Deployed"
+	ConditionTypeReleaseFailed      = "ReleaseFailed"
+	ConditionTypeDriftDetected      = "DriftDetected"
+	ConditionTypeIrreconcilable     = "Irreconcilable"
+	ConditionTypeProvenanceVerified = "ProvenanceVerified"
+)
+
+// RollbackEnabled reports whether automatic rollback/retry was requested.
+func (chartSpec ChartSpec) RollbackEnabled() bool {
+	return chartSpec.Rollback != nil && chartSpec.Rollback.Enabled
+}
+
+// MaxRetriesReached reports whether retryCount has reached spec.rollback.maxRetries.
+// A maxRetries of zero means retry indefinitely.
+func (chartSpec ChartSpec) MaxRetriesReached(retryCount int) bool {
+	if chartSpec.Rollback == nil || chartSpec.Rollback.MaxRetries == 0 {
+		return false
+	}
+	return retryCount >= chartSpec.Rollback.MaxRetries
+}
+
+// SetCondition upserts a condition by Type, matching the semantics of
+// k8s.io/apimachinery/pkg/api/meta's SetStatusCondition.
+func (s *ChartStatus) SetCondition(condition metav1.Condition) {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == condition.Type {
+			if s.Conditions[i].Status != condition.Status {
+				condition.LastTransitionTime = metav1.Now()
+			} else {
+				condition.LastTransitionTime = s.Conditions[i].LastTransitionTime
+			}
+			s.Conditions[i] = condition
+			return
+		}
+	}
+	if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	s.Conditions = append(s.Conditions, condition)
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ChartList defines a list of Charts
+type ChartList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Chart `json:"items"`
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1beta1
+
+import "strings"
+
+// HelmExtensions specifies settings for cluster helm based extensions
+type HelmExtensions struct {
+	Repositories RepositoriesSettings `json:"repositories,omitempty"`
+	Charts       ChartsSettings       `json:"charts,omitempty"`
+	// Verify, when set, is the default provenance verification policy
+	// applied to every chart that doesn't specify its own.
+	Verify *VerifyPolicy `json:"verify,omitempty"`
+}
+
+// VerifyPolicy mirrors v1beta1.VerifyPolicy in the helm.k0sproject.io API
+// group, configuring chart provenance verification.
+type VerifyPolicy struct {
+	Enabled          bool   `json:"enabled,omitempty"`
+	KeyringSecretRef string `json:"keyringSecretRef,omitempty"`
+}
+
+// RepositoriesSettings defines the configuration for a chart repository
+type RepositoriesSettings []Repository
+
+// ChartsSettings defines the settings for cluster charts
+type ChartsSettings []Chart
+
+// Chart single helm addon
+type Chart struct {
+	Name      string `json:"name"`
+	ChartName string `json:"chartname"`
+	Version   string `json:"version"`
+	Values    string `json:"values"`
+	TargetNS  string `json:"namespace"`
+	// ValuesFrom merges values sourced from ConfigMaps/Secrets on top of Values, in order.
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+	// Verify overrides the cluster-wide HelmExtensions.Verify default for this chart.
+	Verify *VerifyPolicy `json:"verify,omitempty"`
+}
+
+// ValuesReference mirrors v1beta1.ValuesReference in the helm.k0sproject.io API group.
+type ValuesReference struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Key        string `json:"key"`
+	PathPrefix string `json:"pathPrefix,omitempty"`
+}
+
+// Repository describes a single repository entry. Name must be unique within
+// a given list of repositories.
+type Repository struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// CAFile is the path to a CA bundle used to verify the repository's certificate
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile is the path to the client certificate used to authenticate against the repository
+	CertFile string `json:"certFile,omitempty"`
+	// KeyFile is the path to the client key used to authenticate against the repository
+	KeyFile string `json:"keyfile,omitempty"`
+	// Insecure skips TLS certificate verification when connecting to the repository
+	Insecure bool `json:"insecure,omitempty"`
+	// Username is the basic auth username used to connect to the repository
+	Username string `json:"username,omitempty"`
+	// Password is the basic auth password used to connect to the repository
+	Password string `json:"password,omitempty"`
+	// RegistryConfig holds the login credentials for an OCI registry backed repository.
+	RegistryConfig *RegistryConfig `json:"registryConfig,omitempty"`
+}
+
+// RegistryConfig describes the credentials and TLS material used to log in to an OCI registry.
+type RegistryConfig struct {
+	// Username for basic auth against the registry
+	Username string `json:"username,omitempty"`
+	// Password for basic auth against the registry
+	Password string `json:"password,omitempty"`
+	// BearerToken, when set, is used instead of Username/Password
+	BearerToken string `json:"bearerToken,omitempty"`
+	// Insecure skips TLS certificate verification when connecting to the registry
+	Insecure bool `json:"insecure,omitempty"`
+	// CAFile is the path to a CA bundle used to verify the registry's certificate
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile is the path to the client certificate used to authenticate against the registry
+	CertFile string `json:"certFile,omitempty"`
+	// KeyFile is the path to the client key used to authenticate against the registry
+	KeyFile string `json:"keyfile,omitempty"`
+}
+
+// IsOCI reports whether the repository is backed by an OCI registry rather
+// than a classic chart repository index.
+func (r Repository) IsOCI() bool {
+	return strings.HasPrefix(r.URL, "oci://")
+}
@@ -32,17 +32,43 @@ import (
 	kubeutil "github.com/k0sproject/k0s/pkg/kubernetes"
 	"github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// chartHelm is the subset of *helm.Commands that ChartReconciler drives,
+// pulled out as an interface so tests can exercise the reconciler's
+// retry/drift/condition logic against a fake instead of a real Helm client.
+type chartHelm interface {
+	InstallChart(chartName, version, namespace string, values map[string]interface{}, keyringPath string, owner metav1.OwnerReference) (*release.Release, error)
+	UpgradeChart(chartName, version, releaseName, namespace string, values map[string]interface{}, keyringPath string, owner metav1.OwnerReference, forceUpgrade bool, maxHistory int) (*release.Release, error)
+	UninstallRelease(releaseName, namespace string) error
+	Rollback(releaseName string, toRevision int, timeout time.Duration, cleanupOnFail bool) error
+}
+
+var _ chartHelm = &helm.Commands{}
+
+// releasePlanner is the subset of *helm.ReleaseManager that ChartReconciler
+// drives, pulled out as an interface so tests can exercise the
+// Plan->reconcilePlan wiring in updateOrInstallChart against a fake instead
+// of a real Helm client.
+type releasePlanner interface {
+	Plan(chartName, version, releaseName, namespace string, values map[string]interface{}, owner metav1.OwnerReference, keyringPath string) (*helm.Plan, error)
+}
+
+var _ releasePlanner = &helm.ReleaseManager{}
+
 // Helm watch for Chart crd
 type ExtensionsController struct {
 	saver         manifestsSaver
@@ -70,6 +96,18 @@ const (
 	namespaceToWatch = "kube-system"
 )
 
+// inNamespaceToWatch builds a predicate matching objects in namespaceToWatch,
+// the same namespace the Chart CR itself is restricted to. It's shared by
+// the Chart watch and the ConfigMap/Secret valuesFrom watches so the latter
+// only enqueue Charts for changes in that namespace. It filters events only:
+// the manager's cache still lists and watches ConfigMaps/Secrets in every
+// namespace.
+func inNamespaceToWatch() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(object client.Object) bool {
+		return object.GetNamespace() == namespaceToWatch
+	})
+}
+
 // Run runs the extensions controller
 func (ec *ExtensionsController) Reconcile(ctx context.Context, clusterConfig *k0sAPI.ClusterConfig) error {
 	ec.L.Info("Extensions reconcilation started")
@@ -124,6 +162,9 @@ func (ec *ExtensionsController) reconcileHelmExtensions(helmSpec *k0sAPI.HelmExt
 	}
 
 	for _, chart := range helmSpec.Charts {
+		if chart.Verify == nil {
+			chart.Verify = helmSpec.Verify
+		}
 		tw := templatewriter.TemplateWriter{
 			Name:     "addon_crd_manifest",
 			Template: chartCrdTemplate,
@@ -149,9 +190,10 @@ func (ec *ExtensionsController) reconcileHelmExtensions(helmSpec *k0sAPI.HelmExt
 
 type ChartReconciler struct {
 	client.Client
-	helm          *helm.Commands
-	leaderElector LeaderElector
-	L             *logrus.Entry
+	helm           chartHelm
+	releaseManager releasePlanner
+	leaderElector  LeaderElector
+	L              *logrus.Entry
 }
 
 func (cr *ChartReconciler) InjectClient(c client.Client) error {
@@ -201,27 +243,106 @@ func (cr *ChartReconciler) uninstall(ctx context.Context, chart v1beta1.Chart) e
 }
 
 func (cr *ChartReconciler) updateOrInstallChart(ctx context.Context, chart v1beta1.Chart) error {
-	var err error
+	chart.Status.AppliedRevision = chart.Generation
+
+	// valuesFrom references are resolved against the Chart CR's own
+	// namespace (kube-system), not chart.Spec.Namespace (the target install
+	// namespace), so that the valuesFrom watch below -- which lists Charts
+	// by the ConfigMap/Secret's namespace -- can actually find them.
+	values, err := helm.ResolveValues(ctx, cr.Client, chart.GetNamespace(), chart.Spec)
+	if err != nil {
+		return cr.reportFailure(ctx, chart, fmt.Errorf("can't resolve values for `%s`: %w", chart.GetName(), err))
+	}
+
+	var keyringPath string
+	if chart.Spec.VerifyEnabled() {
+		path, cleanup, err := helm.MaterializeKeyring(ctx, cr.Client, chart.GetNamespace(), chart.Spec.Verify.KeyringSecretRef)
+		if err != nil {
+			chart.Status.SetCondition(metav1.Condition{
+				Type:    v1beta1.ConditionTypeProvenanceVerified,
+				Status:  metav1.ConditionFalse,
+				Reason:  "KeyringUnavailable",
+				Message: err.Error(),
+			})
+			return cr.reportFailure(ctx, chart, fmt.Errorf("can't verify provenance for `%s`: %w", chart.GetName(), err))
+		}
+		defer cleanup()
+		keyringPath = path
+	}
+
+	releaseName := chart.Status.ReleaseName
+	plan, err := cr.releaseManager.Plan(chart.Spec.ChartName,
+		chart.Spec.Version,
+		releaseName,
+		chart.Spec.Namespace,
+		values,
+		chartOwnerRef(chart),
+		keyringPath,
+	)
+	if err != nil {
+		if keyringPath != "" {
+			chart.Status.SetCondition(metav1.Condition{
+				Type:    v1beta1.ConditionTypeProvenanceVerified,
+				Status:  metav1.ConditionFalse,
+				Reason:  "VerificationFailed",
+				Message: err.Error(),
+			})
+		}
+		return cr.reportFailure(ctx, chart, fmt.Errorf("can't plan reconcile for `%s`: %w", chart.GetName(), err))
+	}
+	if keyringPath != "" {
+		chart.Status.SetCondition(metav1.Condition{
+			Type:    v1beta1.ConditionTypeProvenanceVerified,
+			Status:  metav1.ConditionTrue,
+			Reason:  "VerificationSucceeded",
+			Message: "chart provenance verified against the configured keyring",
+		})
+	}
+	if err := plan.DetectLiveDrift(ctx, cr.Client); err != nil {
+		cr.L.WithError(err).Warnf("can't detect live drift for `%s`", chart.GetName())
+	}
+	chart.Status.SetCondition(driftCondition(plan))
+
+	return cr.reconcilePlan(ctx, chart, plan, releaseName, values, keyringPath)
+}
+
+// reconcilePlan applies plan: it skips the reconcile entirely when plan has
+// neither spec drift, live drift, nor a forced upgrade, otherwise it installs
+// or upgrades the release and records the outcome on chart.Status. Split out
+// of updateOrInstallChart so the skip-path/condition-reset behaviour can be
+// exercised with a fake chartHelm instead of a real Helm client.
+func (cr *ChartReconciler) reconcilePlan(ctx context.Context, chart v1beta1.Chart, plan *helm.Plan, releaseName string, values map[string]interface{}, keyringPath string) error {
+	if !plan.HasDrift() && !plan.HasLiveDrift() && !chart.Spec.ForceUpgradeEnabled() {
+		cr.L.Debugf("no drift detected for `%s`, skipping reconcile", chart.GetName())
+		clearFailureConditions(&chart, "NoDriftDetected")
+		return cr.Client.Status().Update(ctx, &chart)
+	}
+
 	var chartRelease *release.Release
-	if chart.Status.ReleaseName == "" {
-		// new chartRelease
+	var err error
+	if releaseName == "" {
 		chartRelease, err = cr.helm.InstallChart(chart.Spec.ChartName,
 			chart.Spec.Version,
 			chart.Spec.Namespace,
-			chart.Spec.YamlValues())
+			values,
+			keyringPath,
+			chartOwnerRef(chart))
 		if err != nil {
-			return fmt.Errorf("can't reconcile installation for `%s`: %v", chart.GetName(), err)
+			return cr.reportFailure(ctx, chart, fmt.Errorf("can't reconcile installation for `%s`: %w", chart.GetName(), err))
 		}
 	} else {
-		// update
 		chartRelease, err = cr.helm.UpgradeChart(chart.Spec.ChartName,
-			chart.Status.Version,
-			chart.Status.ReleaseName,
-			chart.Status.Namespace,
-			chart.Spec.YamlValues(),
+			chart.Spec.Version,
+			releaseName,
+			chart.Spec.Namespace,
+			values,
+			keyringPath,
+			chartOwnerRef(chart),
+			chart.Spec.ForceUpgradeEnabled(),
+			chart.Spec.MaxHistory,
 		)
 		if err != nil {
-			return fmt.Errorf("can't reconcile upgrade for `%s`: %v", chart.GetName(), err)
+			return cr.reportFailure(ctx, chart, fmt.Errorf("can't reconcile upgrade for `%s`: %w", chart.GetName(), err))
 		}
 	}
 
@@ -231,14 +352,128 @@ func (cr *ChartReconciler) updateOrInstallChart(ctx context.Context, chart v1bet
 	chart.Status.Updated = time.Now().String()
 	chart.Status.Revision = int64(chartRelease.Version)
 	chart.Status.Namespace = chartRelease.Namespace
-	chart.Status.Error = ""
-	err = cr.Client.Status().Update(ctx, &chart)
-	if err != nil {
+	chart.Status.SetCondition(metav1.Condition{
+		Type:    v1beta1.ConditionTypeDeployed,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ReconcileSucceeded",
+		Message: fmt.Sprintf("revision %d deployed", chartRelease.Version),
+	})
+	clearFailureConditions(&chart, "ReconcileSucceeded")
+	chart.Status.History = appendRevision(chart.Status.History, chart.Spec.MaxHistory, v1beta1.ChartRevision{
+		Revision:   chart.Status.Revision,
+		Version:    chart.Status.Version,
+		AppVersion: chart.Status.AppVersion,
+		Updated:    chart.Status.Updated,
+	})
+	if err := cr.Client.Status().Update(ctx, &chart); err != nil {
 		return fmt.Errorf("can't update status for `%s`: %v", chart.GetName(), err)
 	}
 	return nil
 }
 
+// appendRevision records revision as the most recent entry in history,
+// trimming the oldest entries once maxHistory is reached (0 means unbounded).
+func appendRevision(history []v1beta1.ChartRevision, maxHistory int, revision v1beta1.ChartRevision) []v1beta1.ChartRevision {
+	history = append(history, revision)
+	if maxHistory > 0 && len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	return history
+}
+
+// clearFailureConditions resets ReleaseFailed/Irreconcilable to False and
+// zeroes RetryCount, shared by both the no-drift skip path and the
+// successful install/upgrade path so a chart that recovers without ever
+// hitting reportFailure again doesn't keep reporting a stale failure.
+func clearFailureConditions(chart *v1beta1.Chart, reason string) {
+	chart.Status.SetCondition(metav1.Condition{
+		Type:   v1beta1.ConditionTypeReleaseFailed,
+		Status: metav1.ConditionFalse,
+		Reason: reason,
+	})
+	chart.Status.SetCondition(metav1.Condition{
+		Type:   v1beta1.ConditionTypeIrreconcilable,
+		Status: metav1.ConditionFalse,
+		Reason: reason,
+	})
+	chart.Status.RetryCount = 0
+}
+
+// reportFailure records the failure on the chart's status and, when
+// spec.rollback is enabled, rolls the release back to its last successful
+// revision. Once spec.rollback.maxRetries is reached it marks the chart
+// Irreconcilable and returns nil so the controller stops requeuing it.
+func (cr *ChartReconciler) reportFailure(ctx context.Context, chart v1beta1.Chart, reconcileErr error) error {
+	chart.Status.RetryCount++
+	chart.Status.SetCondition(metav1.Condition{
+		Type:    v1beta1.ConditionTypeReleaseFailed,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ReconcileFailed",
+		Message: reconcileErr.Error(),
+	})
+
+	if chart.Spec.RollbackEnabled() && chart.Status.ReleaseName != "" && len(chart.Status.History) > 0 {
+		lastGood := chart.Status.History[len(chart.Status.History)-1]
+		timeout := chart.Spec.Rollback.Timeout.Duration
+		if err := cr.helm.Rollback(chart.Status.ReleaseName, int(lastGood.Revision), timeout, chart.Spec.Rollback.CleanupOnFail); err != nil {
+			cr.L.WithError(err).Errorf("can't roll back `%s` to revision %d", chart.GetName(), lastGood.Revision)
+		} else {
+			chart.Status.Version = lastGood.Version
+			chart.Status.AppVersion = lastGood.AppVersion
+			chart.Status.Revision = lastGood.Revision
+		}
+	}
+
+	var err error
+	if chart.Spec.RollbackEnabled() && chart.Spec.MaxRetriesReached(chart.Status.RetryCount) {
+		chart.Status.SetCondition(metav1.Condition{
+			Type:    v1beta1.ConditionTypeIrreconcilable,
+			Status:  metav1.ConditionTrue,
+			Reason:  "MaxRetriesExceeded",
+			Message: reconcileErr.Error(),
+		})
+	} else {
+		err = reconcileErr
+	}
+
+	if updateErr := cr.Client.Status().Update(ctx, &chart); updateErr != nil {
+		cr.L.WithError(updateErr).Errorf("can't update status for `%s`", chart.GetName())
+	}
+	return err
+}
+
+func driftCondition(plan *helm.Plan) metav1.Condition {
+	if plan.HasLiveDrift() {
+		return metav1.Condition{
+			Type:    v1beta1.ConditionTypeDriftDetected,
+			Status:  metav1.ConditionTrue,
+			Reason:  "LiveStateChanged",
+			Message: plan.LiveDrift,
+		}
+	}
+	return metav1.Condition{
+		Type:   v1beta1.ConditionTypeDriftDetected,
+		Status: metav1.ConditionFalse,
+		Reason: "NoDriftDetected",
+	}
+}
+
+// chartOwnerRef builds the owner reference stamped onto every object
+// rendered for chart, so that cluster-admin edits to those objects can be
+// attributed back to the owning Chart CR.
+func chartOwnerRef(chart v1beta1.Chart) metav1.OwnerReference {
+	blockOwnerDeletion := true
+	isController := true
+	return metav1.OwnerReference{
+		APIVersion:         v1beta1.GroupVersion.String(),
+		Kind:               "Chart",
+		Name:               chart.GetName(),
+		UID:                chart.GetUID(),
+		BlockOwnerDeletion: &blockOwnerDeletion,
+		Controller:         &isController,
+	}
+}
+
 func (ec *ExtensionsController) addRepo(repo k0sAPI.Repository) error {
 	return ec.helm.AddRepository(repo)
 }
@@ -257,6 +492,22 @@ spec:
 {{ .Values | nindent 4 }}
   version: {{ .Version }}
   namespace: {{ .TargetNS }}
+{{- if .ValuesFrom }}
+  valuesFrom:
+{{- range .ValuesFrom }}
+    - kind: {{ .Kind }}
+      name: {{ .Name }}
+      key: {{ .Key }}
+      {{- if .PathPrefix }}
+      pathPrefix: {{ .PathPrefix }}
+      {{- end }}
+{{- end }}
+{{- end }}
+{{- if .Verify }}
+  verify:
+    enabled: {{ .Verify.Enabled }}
+    keyringSecretRef: {{ .Verify.KeyringSecretRef }}
+{{- end }}
 `
 
 const finalizerName = "helm.k0sproject.io/uninstall-helm-release"
@@ -305,16 +556,17 @@ func (ec *ExtensionsController) Run(ctx context.Context) error {
 		For(&v1beta1.Chart{},
 			builder.WithPredicates(predicate.And(
 				predicate.GenerationChangedPredicate{},
-				predicate.NewPredicateFuncs(func(object client.Object) bool {
-					return object.GetNamespace() == namespaceToWatch
-				}),
+				inNamespaceToWatch(),
 			),
 			),
 		).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(ec.chartsUsingValuesFrom(mgr.GetClient(), "ConfigMap")), builder.WithPredicates(inNamespaceToWatch())).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(ec.chartsUsingValuesFrom(mgr.GetClient(), "Secret")), builder.WithPredicates(inNamespaceToWatch())).
 		Complete(&ChartReconciler{
-			leaderElector: ec.leaderElector, // TODO: drop in favor of controller-runtime lease manager?
-			helm:          ec.helm,
-			L:             ec.L.WithField("extensions_type", "helm"),
+			leaderElector:  ec.leaderElector, // TODO: drop in favor of controller-runtime lease manager?
+			helm:           ec.helm,
+			releaseManager: helm.NewReleaseManager(ec.helm),
+			L:              ec.L.WithField("extensions_type", "helm"),
 		}); err != nil {
 		return fmt.Errorf("can't build controller-runtime controller for helm extensions: %w", err)
 	}
@@ -328,6 +580,30 @@ func (ec *ExtensionsController) Run(ctx context.Context) error {
 	return nil
 }
 
+// chartsUsingValuesFrom returns a map function that requeues every Chart in
+// the changed object's namespace whose spec.valuesFrom references it, so
+// rotating a credential in a Secret/ConfigMap is picked up without waiting
+// for the Chart's own generation to change.
+func (ec *ExtensionsController) chartsUsingValuesFrom(kubeClient client.Client, kind string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		var charts v1beta1.ChartList
+		if err := kubeClient.List(context.Background(), &charts, client.InNamespace(obj.GetNamespace())); err != nil {
+			ec.L.WithError(err).Warn("can't list charts for valuesFrom watch")
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, chart := range charts.Items {
+			for _, ref := range chart.Spec.ValuesFrom {
+				if ref.Kind == kind && ref.Name == obj.GetName() {
+					requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&chart)})
+					break
+				}
+			}
+		}
+		return requests
+	}
+}
+
 // Stop
 func (ec *ExtensionsController) Stop() error {
 	return nil
@@ -0,0 +1,344 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/k0sproject/k0s/pkg/apis/helm.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0s/pkg/helm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	chart2 "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeChartHelm is a chartHelm that records calls and returns canned
+// results/errors, so reconciler logic can be tested without a real Helm client.
+type fakeChartHelm struct {
+	installRelease *release.Release
+	installErr     error
+	upgradeRelease *release.Release
+	upgradeErr     error
+	rollbackErr    error
+	rollbackCalls  int
+	rollbackToRevs []int
+
+	upgradeVersion   string
+	upgradeNamespace string
+}
+
+func (f *fakeChartHelm) InstallChart(string, string, string, map[string]interface{}, string, metav1.OwnerReference) (*release.Release, error) {
+	return f.installRelease, f.installErr
+}
+
+func (f *fakeChartHelm) UpgradeChart(chartName, version, releaseName, namespace string, values map[string]interface{}, keyringPath string, owner metav1.OwnerReference, forceUpgrade bool, maxHistory int) (*release.Release, error) {
+	f.upgradeVersion = version
+	f.upgradeNamespace = namespace
+	return f.upgradeRelease, f.upgradeErr
+}
+
+func (f *fakeChartHelm) UninstallRelease(string, string) error {
+	return nil
+}
+
+func (f *fakeChartHelm) Rollback(_ string, toRevision int, _ time.Duration, _ bool) error {
+	f.rollbackCalls++
+	f.rollbackToRevs = append(f.rollbackToRevs, toRevision)
+	return f.rollbackErr
+}
+
+var errBoom = errors.New("boom")
+
+// fakeReleasePlanner is a releasePlanner that returns a canned plan/error,
+// so updateOrInstallChart's Plan->reconcilePlan wiring can be exercised
+// without a real Helm client.
+type fakeReleasePlanner struct {
+	plan *helm.Plan
+	err  error
+}
+
+func (f *fakeReleasePlanner) Plan(string, string, string, string, map[string]interface{}, metav1.OwnerReference, string) (*helm.Plan, error) {
+	return f.plan, f.err
+}
+
+func newTestChartReconciler(t *testing.T, fakeHelm *fakeChartHelm, initObjs ...client.Object) *ChartReconciler {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initObjs...).Build()
+	return &ChartReconciler{
+		Client: kubeClient,
+		helm:   fakeHelm,
+		L:      logrus.NewEntry(logrus.New()),
+	}
+}
+
+func TestAppendRevision(t *testing.T) {
+	rev := func(n int64) v1beta1.ChartRevision {
+		return v1beta1.ChartRevision{Revision: n}
+	}
+
+	t.Run("unbounded history keeps every revision", func(t *testing.T) {
+		history := []v1beta1.ChartRevision{rev(1), rev(2)}
+		history = appendRevision(history, 0, rev(3))
+		assert.Equal(t, []v1beta1.ChartRevision{rev(1), rev(2), rev(3)}, history)
+	})
+
+	t.Run("bounded history trims the oldest entries", func(t *testing.T) {
+		history := []v1beta1.ChartRevision{rev(1), rev(2)}
+		history = appendRevision(history, 2, rev(3))
+		assert.Equal(t, []v1beta1.ChartRevision{rev(2), rev(3)}, history)
+	})
+
+	t.Run("maxHistory of 1 keeps only the latest", func(t *testing.T) {
+		history := []v1beta1.ChartRevision{rev(1)}
+		history = appendRevision(history, 1, rev(2))
+		assert.Equal(t, []v1beta1.ChartRevision{rev(2)}, history)
+	})
+}
+
+func TestDriftCondition(t *testing.T) {
+	t.Run("live drift", func(t *testing.T) {
+		cond := driftCondition(&helm.Plan{LiveDrift: "~ v1/ConfigMap/kube-system/foo"})
+		assert.Equal(t, v1beta1.ConditionTypeDriftDetected, cond.Type)
+		assert.Equal(t, metav1.ConditionTrue, cond.Status)
+		assert.Equal(t, "LiveStateChanged", cond.Reason)
+	})
+
+	t.Run("no live drift", func(t *testing.T) {
+		cond := driftCondition(&helm.Plan{})
+		assert.Equal(t, v1beta1.ConditionTypeDriftDetected, cond.Type)
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	})
+}
+
+func testChart(name string) v1beta1.Chart {
+	return v1beta1.Chart{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system"},
+		Status: v1beta1.ChartStatus{
+			RetryCount: 2,
+			Conditions: []metav1.Condition{
+				{Type: v1beta1.ConditionTypeReleaseFailed, Status: metav1.ConditionTrue, Reason: "ReconcileFailed"},
+				{Type: v1beta1.ConditionTypeIrreconcilable, Status: metav1.ConditionTrue, Reason: "MaxRetriesExceeded"},
+			},
+		},
+	}
+}
+
+func TestReconcilePlan(t *testing.T) {
+	t.Run("no drift resets a previously failed chart", func(t *testing.T) {
+		chart := testChart("no-drift")
+		cr := newTestChartReconciler(t, &fakeChartHelm{}, &chart)
+
+		err := cr.reconcilePlan(context.Background(), chart, &helm.Plan{Release: &release.Release{}}, "existing-release", nil, "")
+		require.NoError(t, err)
+
+		var got v1beta1.Chart
+		require.NoError(t, cr.Client.Get(context.Background(), client.ObjectKeyFromObject(&chart), &got))
+		assertConditionStatus(t, got, v1beta1.ConditionTypeReleaseFailed, metav1.ConditionFalse)
+		assertConditionStatus(t, got, v1beta1.ConditionTypeIrreconcilable, metav1.ConditionFalse)
+		assert.Equal(t, 0, got.Status.RetryCount)
+	})
+
+	t.Run("live drift alone still triggers an upgrade", func(t *testing.T) {
+		chart := testChart("live-drift")
+		fakeHelm := &fakeChartHelm{upgradeRelease: &release.Release{
+			Name:    "existing-release",
+			Version: 2,
+			Chart:   &chart2.Chart{Metadata: &chart2.Metadata{Version: "1.2.3"}},
+		}}
+		cr := newTestChartReconciler(t, fakeHelm, &chart)
+
+		err := cr.reconcilePlan(context.Background(), chart, &helm.Plan{Release: &release.Release{}, LiveDrift: "~ v1/ConfigMap/kube-system/foo"}, "existing-release", nil, "")
+		require.NoError(t, err)
+
+		var got v1beta1.Chart
+		require.NoError(t, cr.Client.Get(context.Background(), client.ObjectKeyFromObject(&chart), &got))
+		assert.Equal(t, int64(2), got.Status.Revision)
+		assertConditionStatus(t, got, v1beta1.ConditionTypeDeployed, metav1.ConditionTrue)
+	})
+
+	t.Run("upgrade re-locates the chart at the new spec version, not the stale status version", func(t *testing.T) {
+		chart := testChart("version-bump")
+		chart.Spec.Version = "2.0.0"
+		chart.Spec.Namespace = "new-ns"
+		chart.Status.Version = "1.0.0"
+		chart.Status.Namespace = "old-ns"
+		fakeHelm := &fakeChartHelm{upgradeRelease: &release.Release{
+			Name:    "existing-release",
+			Version: 2,
+			Chart:   &chart2.Chart{Metadata: &chart2.Metadata{Version: "2.0.0"}},
+		}}
+		cr := newTestChartReconciler(t, fakeHelm, &chart)
+
+		err := cr.reconcilePlan(context.Background(), chart, &helm.Plan{Release: &release.Release{}, Diff: "~ version"}, "existing-release", nil, "")
+		require.NoError(t, err)
+
+		assert.Equal(t, "2.0.0", fakeHelm.upgradeVersion)
+		assert.Equal(t, "new-ns", fakeHelm.upgradeNamespace)
+	})
+
+	t.Run("drift installs a new release", func(t *testing.T) {
+		chart := testChart("install")
+		fakeHelm := &fakeChartHelm{installRelease: &release.Release{
+			Name:    "install",
+			Version: 1,
+			Chart:   &chart2.Chart{Metadata: &chart2.Metadata{Version: "1.2.3"}},
+		}}
+		cr := newTestChartReconciler(t, fakeHelm, &chart)
+
+		err := cr.reconcilePlan(context.Background(), chart, &helm.Plan{}, "", nil, "")
+		require.NoError(t, err)
+
+		var got v1beta1.Chart
+		require.NoError(t, cr.Client.Get(context.Background(), client.ObjectKeyFromObject(&chart), &got))
+		assert.Equal(t, "install", got.Status.ReleaseName)
+		assert.Equal(t, "1.2.3", got.Status.Version)
+		assertConditionStatus(t, got, v1beta1.ConditionTypeDeployed, metav1.ConditionTrue)
+		assertConditionStatus(t, got, v1beta1.ConditionTypeReleaseFailed, metav1.ConditionFalse)
+	})
+
+	t.Run("install failure reports failure instead of erroring the reconcile directly", func(t *testing.T) {
+		chart := testChart("install-fails")
+		fakeHelm := &fakeChartHelm{installErr: errBoom}
+		cr := newTestChartReconciler(t, fakeHelm, &chart)
+
+		err := cr.reconcilePlan(context.Background(), chart, &helm.Plan{}, "", nil, "")
+		require.Error(t, err)
+
+		var got v1beta1.Chart
+		require.NoError(t, cr.Client.Get(context.Background(), client.ObjectKeyFromObject(&chart), &got))
+		assertConditionStatus(t, got, v1beta1.ConditionTypeReleaseFailed, metav1.ConditionTrue)
+	})
+}
+
+func TestUpdateOrInstallChart(t *testing.T) {
+	t.Run("wires Plan's drift into reconcilePlan so an upgrade lands at the new spec version", func(t *testing.T) {
+		chart := testChart("wired-upgrade")
+		chart.Status.ReleaseName = "existing-release"
+		chart.Spec.ChartName = "repo/chart"
+		chart.Spec.Version = "2.0.0"
+		chart.Spec.Namespace = "target-ns"
+		chart.Status.Version = "1.0.0"
+		chart.Status.Namespace = "old-ns"
+
+		fakeHelm := &fakeChartHelm{upgradeRelease: &release.Release{
+			Name:    "existing-release",
+			Version: 3,
+			Chart:   &chart2.Chart{Metadata: &chart2.Metadata{Version: "2.0.0"}},
+		}}
+		cr := newTestChartReconciler(t, fakeHelm, &chart)
+		cr.releaseManager = &fakeReleasePlanner{plan: &helm.Plan{Release: &release.Release{}, Diff: "~ version"}}
+
+		err := cr.updateOrInstallChart(context.Background(), chart)
+		require.NoError(t, err)
+
+		assert.Equal(t, "2.0.0", fakeHelm.upgradeVersion)
+		assert.Equal(t, "target-ns", fakeHelm.upgradeNamespace)
+
+		var got v1beta1.Chart
+		require.NoError(t, cr.Client.Get(context.Background(), client.ObjectKeyFromObject(&chart), &got))
+		assertConditionStatus(t, got, v1beta1.ConditionTypeDeployed, metav1.ConditionTrue)
+	})
+
+	t.Run("a plan error is reported as a failure instead of erroring the reconcile directly", func(t *testing.T) {
+		chart := testChart("plan-fails")
+		cr := newTestChartReconciler(t, &fakeChartHelm{}, &chart)
+		cr.releaseManager = &fakeReleasePlanner{err: errBoom}
+
+		err := cr.updateOrInstallChart(context.Background(), chart)
+		require.Error(t, err)
+
+		var got v1beta1.Chart
+		require.NoError(t, cr.Client.Get(context.Background(), client.ObjectKeyFromObject(&chart), &got))
+		assertConditionStatus(t, got, v1beta1.ConditionTypeReleaseFailed, metav1.ConditionTrue)
+	})
+}
+
+func TestReportFailure(t *testing.T) {
+	t.Run("increments retry count and returns the error when rollback is disabled", func(t *testing.T) {
+		chart := testChart("no-rollback")
+		chart.Status.RetryCount = 0
+		fakeHelm := &fakeChartHelm{}
+		cr := newTestChartReconciler(t, fakeHelm, &chart)
+
+		err := cr.reportFailure(context.Background(), chart, errBoom)
+		require.Error(t, err)
+		assert.Equal(t, 0, fakeHelm.rollbackCalls)
+
+		var got v1beta1.Chart
+		require.NoError(t, cr.Client.Get(context.Background(), client.ObjectKeyFromObject(&chart), &got))
+		assert.Equal(t, 1, got.Status.RetryCount)
+		assertConditionStatus(t, got, v1beta1.ConditionTypeReleaseFailed, metav1.ConditionTrue)
+	})
+
+	t.Run("rolls back to the last successful revision when rollback is enabled", func(t *testing.T) {
+		chart := testChart("rollback")
+		chart.Status.RetryCount = 0
+		chart.Status.ReleaseName = "rollback"
+		chart.Status.History = []v1beta1.ChartRevision{{Revision: 1, Version: "1.0.0"}, {Revision: 2, Version: "2.0.0"}}
+		chart.Spec.Rollback = &v1beta1.RollbackPolicy{Enabled: true}
+		fakeHelm := &fakeChartHelm{}
+		cr := newTestChartReconciler(t, fakeHelm, &chart)
+
+		err := cr.reportFailure(context.Background(), chart, errBoom)
+		require.Error(t, err)
+		require.Equal(t, 1, fakeHelm.rollbackCalls)
+		assert.Equal(t, []int{2}, fakeHelm.rollbackToRevs)
+
+		var got v1beta1.Chart
+		require.NoError(t, cr.Client.Get(context.Background(), client.ObjectKeyFromObject(&chart), &got))
+		assert.Equal(t, "2.0.0", got.Status.Version)
+		assert.Equal(t, int64(2), got.Status.Revision)
+	})
+
+	t.Run("marks the chart Irreconcilable and swallows the error once maxRetries is reached", func(t *testing.T) {
+		chart := testChart("max-retries")
+		chart.Status.RetryCount = 2
+		chart.Status.ReleaseName = "max-retries"
+		chart.Status.History = []v1beta1.ChartRevision{{Revision: 1}}
+		chart.Spec.Rollback = &v1beta1.RollbackPolicy{Enabled: true, MaxRetries: 3}
+		fakeHelm := &fakeChartHelm{}
+		cr := newTestChartReconciler(t, fakeHelm, &chart)
+
+		err := cr.reportFailure(context.Background(), chart, errBoom)
+		require.NoError(t, err, "once MaxRetriesExceeded, the reconcile stops being requeued")
+
+		var got v1beta1.Chart
+		require.NoError(t, cr.Client.Get(context.Background(), client.ObjectKeyFromObject(&chart), &got))
+		assert.Equal(t, 3, got.Status.RetryCount)
+		assertConditionStatus(t, got, v1beta1.ConditionTypeIrreconcilable, metav1.ConditionTrue)
+	})
+}
+
+func assertConditionStatus(t *testing.T, chart v1beta1.Chart, condType string, status metav1.ConditionStatus) {
+	t.Helper()
+	for _, c := range chart.Status.Conditions {
+		if c.Type == condType {
+			assert.Equal(t, status, c.Status, "condition %s", condType)
+			return
+		}
+	}
+	t.Fatalf("condition %s not found", condType)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/etcdutl/v3/snapshot"
+
+	"github.com/k0sproject/k0s/pkg/apis/k0s.k0sproject.io/v1beta1"
+)
+
+// Client is a wrapper around the etcd v3 client tailored to the handful of
+// maintenance operations k0s needs to drive against the local/cluster etcd.
+type Client struct {
+	endpoints []string
+	tlsConfig *tls.Config
+}
+
+// NewClient creates new prepared client for accessing etcd
+func NewClient(certRootDir, etcdCertDir string, etcdConfig *v1beta1.EtcdConfig) (*Client, error) {
+	tlsInfo := transport.TLSInfo{
+		CertFile:      filepath.Join(etcdCertDir, "apiserver-etcd-client.crt"),
+		KeyFile:       filepath.Join(etcdCertDir, "apiserver-etcd-client.key"),
+		TrustedCAFile: filepath.Join(etcdCertDir, "ca.crt"),
+	}
+	tlsConfig, err := tlsInfo.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can't build etcd tls config: %v", err)
+	}
+
+	return &Client{
+		endpoints: []string{"https://127.0.0.1:2379"},
+		tlsConfig: tlsConfig,
+	}, nil
+}
+
+func (c *Client) newClientV3() (*clientv3.Client, error) {
+	return clientv3.New(clientv3.Config{
+		Endpoints:   c.endpoints,
+		DialTimeout: 5 * time.Second,
+		TLS:         c.tlsConfig,
+	})
+}
+
+// Member defines etcd cluster member properties
+type Member struct {
+	ID      uint64   `json:"id"`
+	Name    string   `json:"name"`
+	PeerURL string   `json:"peerURL"`
+	URLs    []string `json:"urls"`
+}
+
+// ListMembers returns a map of the member names and IDs
+func (c *Client) ListMembers(ctx context.Context) (map[string]uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	etcdClient, err := c.newClientV3()
+	if err != nil {
+		return nil, err
+	}
+	defer etcdClient.Close()
+
+	resp, err := etcdClient.MemberList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't list etcd cluster members: %v", err)
+	}
+	members := make(map[string]uint64, len(resp.Members))
+	for _, member := range resp.Members {
+		members[member.Name] = member.ID
+	}
+	return members, nil
+}
+
+// AddMember adds a new member, identified by peerURL, to the etcd cluster
+// and returns the full post-add member list.
+func (c *Client) AddMember(ctx context.Context, peerURL string) (map[string]uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	etcdClient, err := c.newClientV3()
+	if err != nil {
+		return nil, err
+	}
+	defer etcdClient.Close()
+
+	if _, err := etcdClient.MemberAdd(ctx, []string{peerURL}); err != nil {
+		return nil, fmt.Errorf("can't add etcd cluster member `%s`: %v", peerURL, err)
+	}
+	return c.ListMembers(ctx)
+}
+
+// RemoveMember removes the member identified by memberID from the etcd cluster.
+func (c *Client) RemoveMember(ctx context.Context, memberID uint64) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	etcdClient, err := c.newClientV3()
+	if err != nil {
+		return err
+	}
+	defer etcdClient.Close()
+
+	if _, err := etcdClient.MemberRemove(ctx, memberID); err != nil {
+		return fmt.Errorf("can't remove etcd cluster member `%x`: %v", memberID, err)
+	}
+	return nil
+}
+
+// Defragment triggers an online defragmentation of the local etcd member's backend database.
+func (c *Client) Defragment(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	etcdClient, err := c.newClientV3()
+	if err != nil {
+		return err
+	}
+	defer etcdClient.Close()
+
+	if len(c.endpoints) == 0 {
+		return fmt.Errorf("no etcd endpoints configured")
+	}
+	if _, err := etcdClient.Defragment(ctx, c.endpoints[0]); err != nil {
+		return fmt.Errorf("can't defragment etcd: %v", err)
+	}
+	return nil
+}
+
+// Snapshot streams a point-in-time snapshot of the etcd backend database to dst.
+func (c *Client) Snapshot(ctx context.Context, dst string) error {
+	etcdClient, err := c.newClientV3()
+	if err != nil {
+		return err
+	}
+	defer etcdClient.Close()
+
+	manager := snapshot.NewV3(nil)
+	if err := manager.Save(ctx, etcdClient.Config(), dst); err != nil {
+		return fmt.Errorf("can't save etcd snapshot to `%s`: %v", dst, err)
+	}
+	return nil
+}
+
+// RestoreSnapshot restores the data-dir at dataDir from the snapshot at srcFile,
+// rewriting the cluster's peerURLs for the given member name.
+func (c *Client) RestoreSnapshot(srcFile, dataDir, name, peerURL string) error {
+	manager := snapshot.NewV3(nil)
+	return manager.Restore(snapshot.RestoreConfig{
+		SnapshotPath:        srcFile,
+		Name:                name,
+		OutputDataDir:       dataDir,
+		PeerURLs:            []string{peerURL},
+		InitialCluster:      fmt.Sprintf("%s=%s", name, peerURL),
+		InitialClusterToken: "etcd-cluster",
+		SkipHashCheck:       false,
+	})
+}
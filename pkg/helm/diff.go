@@ -0,0 +1,91 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func parseManifestObjects(manifest string) map[string]unstructured.Unstructured {
+	objects := map[string]unstructured.Unstructured{}
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	for {
+		var obj unstructured.Unstructured
+		if err := decoder.Decode(&obj); err != nil {
+			break
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objects[manifestObjectKey(obj)] = obj
+	}
+	return objects
+}
+
+func manifestObjectKey(obj unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s/%s", obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+}
+
+// unifiedManifestDiff produces a compact, human-readable summary of which
+// objects were added, removed, or changed between two rendered manifests. An
+// empty string means the manifests are equivalent.
+func unifiedManifestDiff(previous, candidate string) string {
+	previousObjects := parseManifestObjects(previous)
+	candidateObjects := parseManifestObjects(candidate)
+
+	var changes []string
+	for key, candidateObj := range candidateObjects {
+		previousObj, ok := previousObjects[key]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("+ %s", key))
+			continue
+		}
+		if !reflect.DeepEqual(normalizeForDiff(previousObj), normalizeForDiff(candidateObj)) {
+			changes = append(changes, fmt.Sprintf("~ %s", key))
+		}
+	}
+	for key := range previousObjects {
+		if _, ok := candidateObjects[key]; !ok {
+			changes = append(changes, fmt.Sprintf("- %s", key))
+		}
+	}
+
+	if len(changes) == 0 {
+		return ""
+	}
+	sort.Strings(changes)
+	return strings.Join(changes, "\n")
+}
+
+// normalizeForDiff strips metadata that Kubernetes mutates server-side
+// (resourceVersion, status, managedFields, ...) so comparisons reflect
+// meaningful spec/config drift rather than bookkeeping churn.
+func normalizeForDiff(obj unstructured.Unstructured) map[string]interface{} {
+	clone := obj.DeepCopy()
+	unstructured.RemoveNestedField(clone.Object, "status")
+	unstructured.RemoveNestedField(clone.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(clone.Object, "metadata", "generation")
+	unstructured.RemoveNestedField(clone.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(clone.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(clone.Object, "metadata", "uid")
+	return clone.Object
+}
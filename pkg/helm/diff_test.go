@@ -0,0 +1,87 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedManifestDiff(t *testing.T) {
+	configMap := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: default
+data:
+  foo: bar
+`
+	configMapChanged := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: default
+data:
+  foo: baz
+`
+	secret := `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+  namespace: default
+`
+
+	t.Run("no previous release", func(t *testing.T) {
+		diff := unifiedManifestDiff("", configMap)
+		assert.Equal(t, "+ v1/ConfigMap/default/cm", diff)
+	})
+
+	t.Run("identical manifests", func(t *testing.T) {
+		diff := unifiedManifestDiff(configMap, configMap)
+		assert.Empty(t, diff)
+	})
+
+	t.Run("changed object", func(t *testing.T) {
+		diff := unifiedManifestDiff(configMap, configMapChanged)
+		assert.Equal(t, "~ v1/ConfigMap/default/cm", diff)
+	})
+
+	t.Run("added and removed objects", func(t *testing.T) {
+		diff := unifiedManifestDiff(configMap, secret)
+		assert.Equal(t, "+ v1/Secret/default/secret\n- v1/ConfigMap/default/cm", diff)
+	})
+
+	t.Run("server-side metadata churn is ignored", func(t *testing.T) {
+		previous := configMap
+		candidate := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: default
+  resourceVersion: "12345"
+  generation: 7
+data:
+  foo: bar
+`
+		diff := unifiedManifestDiff(previous, candidate)
+		assert.Empty(t, diff)
+	})
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/repo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k0sAPI "github.com/k0sproject/k0s/pkg/apis/k0s.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0s/pkg/constant"
+)
+
+// Commands is a wrapper around the Helm v3 action package that k0s uses to
+// drive chart repository and release management.
+type Commands struct {
+	kubeConfig     string
+	helmSettings   *cli.EnvSettings
+	actionConfig   *action.Configuration
+	registryMu     sync.Mutex
+	registryClient *registry.Client
+	registryDir    string
+	logger         *logrus.Entry
+}
+
+// NewCommands builds a Commands instance using the admin kubeconfig for the
+// given node.
+func NewCommands(k0sVars constant.CfgVars) *Commands {
+	helmSettings := cli.New()
+	helmSettings.RepositoryConfig = filepath.Join(k0sVars.DataDir, "helmrepo.yaml")
+	helmSettings.RepositoryCache = filepath.Join(k0sVars.DataDir, "helmcache")
+	helmSettings.KubeConfig = k0sVars.AdminKubeConfigPath
+
+	registryDir := filepath.Join(k0sVars.DataDir, "helmregistry")
+
+	actionConfig := new(action.Configuration)
+	logger := logrus.WithField("component", "helm")
+	_ = actionConfig.Init(helmSettings.RESTClientGetter(), "", os.Getenv("HELM_DRIVER"), func(format string, v ...interface{}) {
+		logger.Debugf(format, v...)
+	})
+
+	return &Commands{
+		kubeConfig:   k0sVars.AdminKubeConfigPath,
+		helmSettings: helmSettings,
+		actionConfig: actionConfig,
+		registryDir:  registryDir,
+		logger:       logger,
+	}
+}
+
+// AddRepository registers a classic HTTP chart repository, or, for `oci://`
+// repositories, persists the registry login so subsequent chart pulls can
+// authenticate against it.
+func (hc *Commands) AddRepository(repoCfg k0sAPI.Repository) error {
+	if repoCfg.IsOCI() {
+		return hc.loginOCIRegistry(repoCfg)
+	}
+
+	chartRepo, err := repo.NewChartRepository(&repo.Entry{
+		Name:                  repoCfg.Name,
+		URL:                   repoCfg.URL,
+		Username:              repoCfg.Username,
+		Password:              repoCfg.Password,
+		CertFile:              repoCfg.CertFile,
+		KeyFile:               repoCfg.KeyFile,
+		CAFile:                repoCfg.CAFile,
+		InsecureSkipTLSverify: repoCfg.Insecure,
+	}, getter.All(hc.helmSettings))
+	if err != nil {
+		return fmt.Errorf("can't create chart repository object for `%s`: %v", repoCfg.URL, err)
+	}
+	chartRepo.CachePath = hc.helmSettings.RepositoryCache
+	if _, err := chartRepo.DownloadIndexFile(); err != nil {
+		return fmt.Errorf("can't add chart repository `%s`: %v", repoCfg.URL, err)
+	}
+
+	repoFile, err := repo.LoadFile(hc.helmSettings.RepositoryConfig)
+	if err != nil {
+		repoFile = repo.NewFile()
+	}
+	repoFile.Update(chartRepo.Config)
+	if err := repoFile.WriteFile(hc.helmSettings.RepositoryConfig, 0644); err != nil {
+		return fmt.Errorf("can't persist chart repository `%s`: %v", repoCfg.URL, err)
+	}
+	return nil
+}
+
+// loginOCIRegistry writes the registry's login config to a per-controller
+// registry config file so later InstallChart/UpgradeChart calls against
+// `oci://` chart references can authenticate transparently.
+func (hc *Commands) loginOCIRegistry(repoCfg k0sAPI.Repository) error {
+	if err := os.MkdirAll(hc.registryDir, 0755); err != nil {
+		return fmt.Errorf("can't create helm registry config dir: %v", err)
+	}
+
+	registryClient, err := registry.NewClient(
+		registry.ClientOptCredentialsFile(filepath.Join(hc.registryDir, "config.json")),
+	)
+	if err != nil {
+		return fmt.Errorf("can't create registry client for `%s`: %v", repoCfg.URL, err)
+	}
+	hc.setRegistryClient(registryClient)
+
+	if repoCfg.RegistryConfig == nil {
+		// anonymous pulls are still valid for public OCI registries
+		return nil
+	}
+
+	host := strings.TrimPrefix(repoCfg.URL, "oci://")
+	cfg := repoCfg.RegistryConfig
+	loginOpts := []registry.LoginOption{registry.LoginOptInsecure(cfg.Insecure)}
+	if cfg.CAFile != "" || cfg.CertFile != "" || cfg.KeyFile != "" {
+		loginOpts = append(loginOpts, registry.LoginOptTLSClientConfig(cfg.CertFile, cfg.KeyFile, cfg.CAFile))
+	}
+	if cfg.BearerToken != "" {
+		loginOpts = append(loginOpts, registry.LoginOptBasicAuth("", cfg.BearerToken))
+	} else {
+		loginOpts = append(loginOpts, registry.LoginOptBasicAuth(cfg.Username, cfg.Password))
+	}
+	return registryClient.Login(host, loginOpts...)
+}
+
+// locateChart resolves a chart reference to a local path, using the OCI
+// getter/registry client for `oci://` references and the classic repository
+// resolver otherwise. When keyringPath is non-empty, the chart's `.prov`
+// file is downloaded alongside the chart and verified against it.
+//
+// `action.ChartPathOptions.LocateChart`'s built-in Verify/Keyring handling is
+// only exercised for classic repositories, so for `oci://` references the
+// provenance file is fetched and verified explicitly afterwards.
+func (hc *Commands) locateChart(chartName, version, keyringPath string) (string, error) {
+	isOCI := strings.HasPrefix(chartName, "oci://")
+
+	install := action.NewInstall(hc.actionConfig)
+	install.ChartPathOptions.Version = version
+	install.Settings = hc.helmSettings
+	if keyringPath != "" && !isOCI {
+		install.ChartPathOptions.Verify = true
+		install.ChartPathOptions.Keyring = keyringPath
+	}
+
+	var registryClient *registry.Client
+	if isOCI {
+		var err error
+		registryClient, err = hc.ensureRegistryClient()
+		if err != nil {
+			return "", fmt.Errorf("can't create registry client for `%s`: %v", chartName, err)
+		}
+	}
+
+	cp, err := install.ChartPathOptions.LocateChart(chartName, hc.helmSettings)
+	if err != nil {
+		return "", err
+	}
+
+	if isOCI && keyringPath != "" {
+		if err := verifyOCIChartProvenance(registryClient, chartName, version, cp, keyringPath); err != nil {
+			return "", err
+		}
+	}
+
+	return cp, nil
+}
+
+// setRegistryClient installs registryClient as the Commands' shared OCI
+// registry client, guarding against concurrent (re)initialization from
+// AddRepository and locateChart running on different goroutines.
+func (hc *Commands) setRegistryClient(registryClient *registry.Client) {
+	hc.registryMu.Lock()
+	defer hc.registryMu.Unlock()
+	hc.registryClient = registryClient
+	hc.actionConfig.RegistryClient = registryClient
+}
+
+// ensureRegistryClient returns the Commands' shared OCI registry client,
+// lazily creating an anonymous one if AddRepository hasn't logged in yet.
+// Guarded by registryMu so concurrent repository and chart reconciles can't
+// race on the same client/actionConfig fields.
+func (hc *Commands) ensureRegistryClient() (*registry.Client, error) {
+	hc.registryMu.Lock()
+	defer hc.registryMu.Unlock()
+	if hc.registryClient != nil {
+		return hc.registryClient, nil
+	}
+
+	registryClient, err := registry.NewClient(
+		registry.ClientOptCredentialsFile(filepath.Join(hc.registryDir, "config.json")),
+	)
+	if err != nil {
+		return nil, err
+	}
+	hc.registryClient = registryClient
+	hc.actionConfig.RegistryClient = registryClient
+	return registryClient, nil
+}
+
+// InstallChart installs a new release of a chart. chartName may either be a
+// `repo/chart` reference resolved against a classic chart repository, or an
+// `oci://` reference resolved against an OCI registry. When keyringPath is
+// non-empty, the chart's provenance is verified against it before install.
+// owner is stamped onto every installed object so out-of-band edits can be
+// attributed and detected as drift.
+func (hc *Commands) InstallChart(chartName, version, namespace string, values map[string]interface{}, keyringPath string, owner metav1.OwnerReference) (*release.Release, error) {
+	cp, err := hc.locateChart(chartName, version, keyringPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't locate chart `%s`: %v", chartName, err)
+	}
+	chartRequested, err := loader.Load(cp)
+	if err != nil {
+		return nil, fmt.Errorf("can't load chart `%s`: %v", chartName, err)
+	}
+
+	install := action.NewInstall(hc.actionConfig)
+	install.Namespace = namespace
+	install.ReleaseName = chartReleaseName(chartName)
+	install.CreateNamespace = true
+	install.Version = version
+	install.PostRenderer = newOwnerRefPostRenderer(owner)
+
+	return install.Run(chartRequested, values)
+}
+
+// UpgradeChart upgrades an existing release in place. When keyringPath is
+// non-empty, the chart's provenance is verified against it before upgrade.
+// owner is stamped onto every upgraded object so out-of-band edits can be
+// attributed and detected as drift. forceUpgrade and maxHistory are passed
+// straight through to the underlying Helm upgrade action's `--force` and
+// `--history-max` equivalents.
+func (hc *Commands) UpgradeChart(chartName, version, releaseName, namespace string, values map[string]interface{}, keyringPath string, owner metav1.OwnerReference, forceUpgrade bool, maxHistory int) (*release.Release, error) {
+	cp, err := hc.locateChart(chartName, version, keyringPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't locate chart `%s`: %v", chartName, err)
+	}
+	chartRequested, err := loader.Load(cp)
+	if err != nil {
+		return nil, fmt.Errorf("can't load chart `%s`: %v", chartName, err)
+	}
+
+	upgrade := action.NewUpgrade(hc.actionConfig)
+	upgrade.Namespace = namespace
+	upgrade.Version = version
+	upgrade.PostRenderer = newOwnerRefPostRenderer(owner)
+	upgrade.Force = forceUpgrade
+	upgrade.MaxHistory = maxHistory
+
+	return upgrade.Run(releaseName, chartRequested, values)
+}
+
+// UninstallRelease removes a previously installed release.
+func (hc *Commands) UninstallRelease(releaseName, namespace string) error {
+	hc.actionConfig.Capabilities = nil
+	uninstall := action.NewUninstall(hc.actionConfig)
+	_, err := uninstall.Run(releaseName)
+	return err
+}
+
+// Rollback rolls releaseName back to toRevision, mirroring `helm rollback`.
+// A toRevision of 0 rolls back to the immediately preceding revision.
+func (hc *Commands) Rollback(releaseName string, toRevision int, timeout time.Duration, cleanupOnFail bool) error {
+	rollback := action.NewRollback(hc.actionConfig)
+	rollback.Version = toRevision
+	rollback.Timeout = timeout
+	rollback.CleanupOnFail = cleanupOnFail
+	return rollback.Run(releaseName)
+}
+
+func chartReleaseName(chartName string) string {
+	parts := strings.Split(strings.TrimPrefix(chartName, "oci://"), "/")
+	return parts[len(parts)-1]
+}
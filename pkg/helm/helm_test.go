@@ -0,0 +1,114 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+
+	k0sAPI "github.com/k0sproject/k0s/pkg/apis/k0s.k0sproject.io/v1beta1"
+)
+
+func newTestCommands(t *testing.T) *Commands {
+	return &Commands{
+		helmSettings: cli.New(),
+		actionConfig: &action.Configuration{},
+		registryDir:  t.TempDir(),
+		logger:       logrus.NewEntry(logrus.New()),
+	}
+}
+
+func TestLoginOCIRegistry(t *testing.T) {
+	t.Run("anonymous login sets up the shared registry client", func(t *testing.T) {
+		hc := newTestCommands(t)
+
+		err := hc.loginOCIRegistry(k0sAPI.Repository{URL: "oci://registry.example.com/charts"})
+		require.NoError(t, err)
+		assert.NotNil(t, hc.registryClient)
+		assert.Same(t, hc.registryClient, hc.actionConfig.RegistryClient)
+	})
+
+	t.Run("returns the login error for unreachable registries", func(t *testing.T) {
+		hc := newTestCommands(t)
+
+		err := hc.loginOCIRegistry(k0sAPI.Repository{
+			URL: "oci://127.0.0.1:1/charts",
+			RegistryConfig: &k0sAPI.RegistryConfig{
+				Username: "user",
+				Password: "pass",
+			},
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestEnsureRegistryClient(t *testing.T) {
+	t.Run("lazily creates an anonymous client", func(t *testing.T) {
+		hc := newTestCommands(t)
+
+		registryClient, err := hc.ensureRegistryClient()
+		require.NoError(t, err)
+		assert.NotNil(t, registryClient)
+		assert.Same(t, registryClient, hc.actionConfig.RegistryClient)
+	})
+
+	t.Run("reuses the client installed by loginOCIRegistry instead of recreating it", func(t *testing.T) {
+		hc := newTestCommands(t)
+		require.NoError(t, hc.loginOCIRegistry(k0sAPI.Repository{URL: "oci://registry.example.com/charts"}))
+		logged := hc.registryClient
+
+		registryClient, err := hc.ensureRegistryClient()
+		require.NoError(t, err)
+		assert.Same(t, logged, registryClient)
+	})
+
+	t.Run("concurrent repository login and chart lookups don't race on the shared client", func(t *testing.T) {
+		hc := newTestCommands(t)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_ = hc.loginOCIRegistry(k0sAPI.Repository{URL: "oci://registry.example.com/charts"})
+			}()
+			go func() {
+				defer wg.Done()
+				_, _ = hc.ensureRegistryClient()
+			}()
+		}
+		wg.Wait()
+		assert.NotNil(t, hc.registryClient)
+	})
+}
+
+func TestLocateChartOCI(t *testing.T) {
+	t.Run("reuses the already-logged-in registry client rather than replacing it", func(t *testing.T) {
+		hc := newTestCommands(t)
+		registryClient, err := hc.ensureRegistryClient()
+		require.NoError(t, err)
+
+		_, _ = hc.locateChart("oci://127.0.0.1:1/charts/foo", "1.2.3", "")
+
+		assert.Same(t, registryClient, hc.registryClient)
+	})
+}
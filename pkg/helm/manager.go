@@ -0,0 +1,169 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReleaseManager renders a single Chart CR's candidate release (as a dry
+// run) and compares it against the deployed release, producing a Plan from
+// which the caller decides whether to install or upgrade. It does not
+// install or upgrade anything itself.
+type ReleaseManager struct {
+	commands *Commands
+}
+
+// NewReleaseManager builds a ReleaseManager on top of the given Commands.
+func NewReleaseManager(commands *Commands) *ReleaseManager {
+	return &ReleaseManager{commands: commands}
+}
+
+// Plan describes the outcome of comparing the currently deployed release
+// against a freshly rendered candidate.
+type Plan struct {
+	// Release is the currently deployed Helm release, or nil if none exists yet.
+	Release *release.Release
+	// Candidate is the newly rendered manifest, with the owner reference stamped on.
+	Candidate string
+	// Diff lists the objects added ("+"), removed ("-") or changed ("~")
+	// between the previous release manifest and Candidate, one sorted
+	// "apiVersion/kind/namespace/name" entry per line.
+	Diff string
+	// LiveDrift lists, in the same format, the objects of the previous
+	// release manifest that are missing ("-") or changed ("~") in the
+	// cluster, i.e. edits made outside of this reconciler's control.
+	LiveDrift string
+}
+
+// HasDrift reports whether the candidate manifest differs from the
+// currently deployed release's manifest, i.e. whether an upgrade is needed.
+func (p Plan) HasDrift() bool {
+	return p.Release == nil || p.Diff != ""
+}
+
+// HasLiveDrift reports whether the live cluster state has been edited
+// outside of this reconciler's control since the last successful apply.
+func (p Plan) HasLiveDrift() bool {
+	return p.LiveDrift != ""
+}
+
+// Plan renders chartName/version/values with an owner reference pointing at
+// owner stamped onto every object, then diffs the rendering against the
+// existing release (if any) to decide whether an upgrade is actually needed.
+// When keyringPath is non-empty, the chart's provenance is verified against
+// it before rendering.
+func (rm *ReleaseManager) Plan(chartName, version, releaseName, namespace string, values map[string]interface{}, owner metav1.OwnerReference, keyringPath string) (*Plan, error) {
+	var existing *release.Release
+	if releaseName != "" {
+		get := action.NewGet(rm.commands.actionConfig)
+		r, err := get.Run(releaseName)
+		if err != nil && err != action.ErrReleaseNotFound {
+			return nil, fmt.Errorf("can't load existing release `%s`: %w", releaseName, err)
+		}
+		existing = r
+	}
+
+	cp, err := rm.commands.locateChart(chartName, version, keyringPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't locate chart `%s`: %w", chartName, err)
+	}
+	chartRequested, err := loader.Load(cp)
+	if err != nil {
+		return nil, fmt.Errorf("can't load chart `%s`: %w", chartName, err)
+	}
+
+	renderer := newOwnerRefPostRenderer(owner)
+
+	var candidateManifest string
+	if existing == nil {
+		install := action.NewInstall(rm.commands.actionConfig)
+		install.Namespace = namespace
+		install.ReleaseName = releaseName
+		if install.ReleaseName == "" {
+			install.ReleaseName = chartReleaseName(chartName)
+		}
+		install.DryRun = true
+		install.ClientOnly = true
+		install.PostRenderer = renderer
+		rel, err := install.Run(chartRequested, values)
+		if err != nil {
+			return nil, fmt.Errorf("can't render candidate manifest for `%s`: %w", chartName, err)
+		}
+		candidateManifest = rel.Manifest
+	} else {
+		upgrade := action.NewUpgrade(rm.commands.actionConfig)
+		upgrade.Namespace = namespace
+		upgrade.DryRun = true
+		upgrade.ClientOnly = true
+		upgrade.PostRenderer = renderer
+		rel, err := upgrade.Run(releaseName, chartRequested, values)
+		if err != nil {
+			return nil, fmt.Errorf("can't render candidate manifest for `%s`: %w", chartName, err)
+		}
+		candidateManifest = rel.Manifest
+	}
+
+	var previousManifest string
+	if existing != nil {
+		previousManifest = existing.Manifest
+	}
+
+	return &Plan{
+		Release:   existing,
+		Candidate: candidateManifest,
+		Diff:      unifiedManifestDiff(previousManifest, candidateManifest),
+	}, nil
+}
+
+// DetectLiveDrift fills in Plan.LiveDrift by fetching the live state of
+// every object in the previous release manifest via kubeClient and
+// comparing it against what the release recorded. It is a no-op for a fresh
+// install, where there is no previous release to compare against.
+func (p *Plan) DetectLiveDrift(ctx context.Context, kubeClient client.Client) error {
+	if p.Release == nil {
+		return nil
+	}
+	previousObjects := parseManifestObjects(p.Release.Manifest)
+
+	var changes []string
+	for key, wantObj := range previousObjects {
+		gotObj := unstructured.Unstructured{}
+		gotObj.SetGroupVersionKind(wantObj.GroupVersionKind())
+		nsName := client.ObjectKey{Namespace: wantObj.GetNamespace(), Name: wantObj.GetName()}
+		if err := kubeClient.Get(ctx, nsName, &gotObj); err != nil {
+			changes = append(changes, fmt.Sprintf("- %s", key))
+			continue
+		}
+		if !reflect.DeepEqual(normalizeForDiff(wantObj), normalizeForDiff(gotObj)) {
+			changes = append(changes, fmt.Sprintf("~ %s", key))
+		}
+	}
+	sort.Strings(changes)
+	p.LiveDrift = strings.Join(changes, "\n")
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"helm.sh/helm/v3/pkg/postrender"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// ownerRefPostRenderer stamps an owner reference pointing at the owning
+// Chart CR onto every rendered object, so that out-of-band edits to the
+// release's objects can be attributed and detected as drift.
+type ownerRefPostRenderer struct {
+	ownerRef metav1.OwnerReference
+}
+
+var _ postrender.PostRenderer = &ownerRefPostRenderer{}
+
+func newOwnerRefPostRenderer(ownerRef metav1.OwnerReference) *ownerRefPostRenderer {
+	return &ownerRefPostRenderer{ownerRef: ownerRef}
+}
+
+// Run implements postrender.PostRenderer, stamping the owner reference onto
+// every document in the rendered manifest.
+func (p *ownerRefPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	out := &bytes.Buffer{}
+	decoder := yaml.NewYAMLOrJSONDecoder(renderedManifests, 4096)
+	for {
+		var obj unstructured.Unstructured
+		if err := decoder.Decode(&obj); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("can't decode rendered manifest: %w", err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		obj.SetOwnerReferences(append(obj.GetOwnerReferences(), p.ownerRef))
+
+		doc, err := k8syaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("can't re-encode rendered manifest: %w", err)
+		}
+		out.WriteString("---\n")
+		out.Write(doc)
+	}
+	return out, nil
+}
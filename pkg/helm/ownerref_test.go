@@ -0,0 +1,82 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func TestOwnerRefPostRendererRun(t *testing.T) {
+	owner := metav1.OwnerReference{
+		APIVersion: "helm.k0sproject.io/v1beta1",
+		Kind:       "Chart",
+		Name:       "my-chart",
+		UID:        "1234",
+	}
+	renderer := newOwnerRefPostRenderer(owner)
+
+	rendered := bytes.NewBufferString(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: default
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+  namespace: default
+  ownerReferences:
+    - apiVersion: v1
+      kind: SomeOtherOwner
+      name: pre-existing
+      uid: "5678"
+`)
+
+	out, err := renderer.Run(rendered)
+	require.NoError(t, err)
+
+	objects := map[string]unstructured.Unstructured{}
+	decoder := yaml.NewYAMLOrJSONDecoder(out, 4096)
+	for {
+		var obj unstructured.Unstructured
+		err := decoder.Decode(&obj)
+		if err != nil {
+			break
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objects[obj.GetKind()] = obj
+	}
+	require.Len(t, objects, 2)
+
+	cm := objects["ConfigMap"]
+	require.Len(t, cm.GetOwnerReferences(), 1)
+	require.Equal(t, owner.Name, cm.GetOwnerReferences()[0].Name)
+
+	secret := objects["Secret"]
+	require.Len(t, secret.GetOwnerReferences(), 2)
+	require.Equal(t, "pre-existing", secret.GetOwnerReferences()[0].Name)
+	require.Equal(t, owner.Name, secret.GetOwnerReferences()[1].Name)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/k0sproject/k0s/pkg/apis/helm.k0sproject.io/v1beta1"
+)
+
+// ResolveValues merges the chart's inline values with every valuesFrom
+// reference, in order, using Helm's standard table-coalescing semantics so
+// later sources win on conflicting keys.
+func ResolveValues(ctx context.Context, kubeClient client.Client, namespace string, spec v1beta1.ChartSpec) (map[string]interface{}, error) {
+	values := spec.YamlValues()
+	for _, ref := range spec.ValuesFrom {
+		resolved, err := resolveValuesReference(ctx, kubeClient, namespace, ref)
+		if err != nil {
+			return nil, fmt.Errorf("can't resolve valuesFrom `%s/%s`: %w", ref.Kind, ref.Name, err)
+		}
+		values = chartutil.CoalesceTables(resolved, values)
+	}
+	return values, nil
+}
+
+func resolveValuesReference(ctx context.Context, kubeClient client.Client, namespace string, ref v1beta1.ValuesReference) (map[string]interface{}, error) {
+	raw, err := getReferenceData(ctx, kubeClient, namespace, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, fmt.Errorf("can't parse key `%s` as yaml: %w", ref.Key, err)
+	}
+
+	if ref.PathPrefix == "" {
+		return values, nil
+	}
+	segments := strings.Split(ref.PathPrefix, ".")
+	for i := len(segments) - 1; i >= 0; i-- {
+		values = map[string]interface{}{segments[i]: values}
+	}
+	return values, nil
+}
+
+func getReferenceData(ctx context.Context, kubeClient client.Client, namespace string, ref v1beta1.ValuesReference) (string, error) {
+	objKey := client.ObjectKey{Namespace: namespace, Name: ref.Name}
+	switch ref.Kind {
+	case "Secret":
+		var secret corev1.Secret
+		if err := kubeClient.Get(ctx, objKey, &secret); err != nil {
+			return "", err
+		}
+		data, ok := secret.Data[ref.Key]
+		if !ok {
+			return "", fmt.Errorf("key `%s` not found in Secret `%s`", ref.Key, ref.Name)
+		}
+		return string(data), nil
+	case "ConfigMap":
+		var cm corev1.ConfigMap
+		if err := kubeClient.Get(ctx, objKey, &cm); err != nil {
+			return "", err
+		}
+		data, ok := cm.Data[ref.Key]
+		if !ok {
+			return "", fmt.Errorf("key `%s` not found in ConfigMap `%s`", ref.Key, ref.Name)
+		}
+		return data, nil
+	default:
+		return "", fmt.Errorf("unsupported valuesFrom kind `%s`, want ConfigMap or Secret", ref.Kind)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k0sproject/k0s/pkg/apis/helm.k0sproject.io/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResolveValuesReference(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "kube-system"},
+		Data:       map[string][]byte{"values.yaml": []byte("password: s3cr3t\n")},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "tuning", Namespace: "kube-system"},
+		Data:       map[string]string{"values.yaml": "replicas: 3\n"},
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret, configMap).Build()
+
+	t.Run("Secret without pathPrefix", func(t *testing.T) {
+		values, err := resolveValuesReference(context.Background(), kubeClient, "kube-system", v1beta1.ValuesReference{
+			Kind: "Secret",
+			Name: "creds",
+			Key:  "values.yaml",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"password": "s3cr3t"}, values)
+	})
+
+	t.Run("ConfigMap with pathPrefix nests the resolved values", func(t *testing.T) {
+		values, err := resolveValuesReference(context.Background(), kubeClient, "kube-system", v1beta1.ValuesReference{
+			Kind:       "ConfigMap",
+			Name:       "tuning",
+			Key:        "values.yaml",
+			PathPrefix: "subchart.config",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"subchart": map[string]interface{}{
+				"config": map[string]interface{}{"replicas": 3},
+			},
+		}, values)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := resolveValuesReference(context.Background(), kubeClient, "kube-system", v1beta1.ValuesReference{
+			Kind: "Secret",
+			Name: "creds",
+			Key:  "missing",
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported kind", func(t *testing.T) {
+		_, err := resolveValuesReference(context.Background(), kubeClient, "kube-system", v1beta1.ValuesReference{
+			Kind: "Deployment",
+			Name: "creds",
+			Key:  "values.yaml",
+		})
+		assert.Error(t, err)
+	})
+}
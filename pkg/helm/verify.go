@@ -0,0 +1,101 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/registry"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// keyringSecretKey is the Secret data key expected to hold the GPG public
+// keyring used to verify a chart's `.prov` file.
+const keyringSecretKey = "keyring.gpg"
+
+// MaterializeKeyring copies the GPG keyring referenced by secretName, in
+// namespace, to a temporary file that Helm's provenance verification can
+// read. The returned cleanup func removes the temporary file and must be
+// called once verification has completed.
+func MaterializeKeyring(ctx context.Context, kubeClient client.Client, namespace, secretName string) (path string, cleanup func(), err error) {
+	var secret corev1.Secret
+	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: secretName}, &secret); err != nil {
+		return "", nil, fmt.Errorf("can't load keyring secret `%s`: %w", secretName, err)
+	}
+	keyring, ok := secret.Data[keyringSecretKey]
+	if !ok {
+		return "", nil, fmt.Errorf("key `%s` not found in keyring secret `%s`", keyringSecretKey, secretName)
+	}
+
+	f, err := os.CreateTemp("", "k0s-helm-keyring-*.gpg")
+	if err != nil {
+		return "", nil, fmt.Errorf("can't create temp keyring file: %w", err)
+	}
+	if _, err := f.Write(keyring); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", nil, fmt.Errorf("can't write temp keyring file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", nil, fmt.Errorf("can't close temp keyring file: %w", err)
+	}
+
+	return f.Name(), func() { os.Remove(f.Name()) }, nil
+}
+
+// verifyOCIChartProvenance downloads the `.prov` file for an `oci://` chart
+// reference alongside its digest and verifies it against keyringPath. Unlike
+// classic repositories, `action.ChartPathOptions.LocateChart` doesn't fetch
+// or verify provenance for OCI references on its own, so this is done
+// explicitly once the chart itself has been pulled to chartPath.
+func verifyOCIChartProvenance(registryClient *registry.Client, chartRef, version, chartPath, keyringPath string) error {
+	ref := ociChartRef(chartRef, version)
+
+	result, err := registryClient.Pull(ref, registry.PullOptWithProv(true))
+	if err != nil {
+		return fmt.Errorf("can't pull provenance for `%s`: %w", chartRef, err)
+	}
+	if result.Prov == nil || len(result.Prov.Data) == 0 {
+		return fmt.Errorf("no provenance file found for `%s`", chartRef)
+	}
+
+	provPath := chartPath + ".prov"
+	if err := os.WriteFile(provPath, result.Prov.Data, 0644); err != nil {
+		return fmt.Errorf("can't write provenance file for `%s`: %w", chartRef, err)
+	}
+	defer os.Remove(provPath)
+
+	if _, err := downloader.VerifyChart(chartPath, keyringPath); err != nil {
+		return fmt.Errorf("can't verify provenance for `%s`: %w", chartRef, err)
+	}
+	return nil
+}
+
+// ociChartRef strips the `oci://` scheme off chartRef and, when version is
+// set, appends it as an OCI tag, producing the ref registryClient.Pull expects.
+func ociChartRef(chartRef, version string) string {
+	ref := strings.TrimPrefix(chartRef, fmt.Sprintf("%s://", registry.OCIScheme))
+	if version != "" {
+		ref = fmt.Sprintf("%s:%s", ref, version)
+	}
+	return ref
+}
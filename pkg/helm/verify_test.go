@@ -0,0 +1,86 @@
+/*
+Copyright 2022 k0s authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/registry"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMaterializeKeyring(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "keyring", Namespace: "kube-system"},
+		Data:       map[string][]byte{keyringSecretKey: []byte("fake-gpg-keyring")},
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+
+	t.Run("writes the keyring to a temp file and cleans it up", func(t *testing.T) {
+		path, cleanup, err := MaterializeKeyring(context.Background(), kubeClient, "kube-system", "keyring")
+		require.NoError(t, err)
+		defer cleanup()
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "fake-gpg-keyring", string(data))
+
+		cleanup()
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		_, _, err := MaterializeKeyring(context.Background(), kubeClient, "kube-system", "missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("missing key in secret", func(t *testing.T) {
+		other := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "no-key", Namespace: "kube-system"},
+			Data:       map[string][]byte{"other": []byte("x")},
+		}
+		kc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(other).Build()
+		_, _, err := MaterializeKeyring(context.Background(), kc, "kube-system", "no-key")
+		assert.Error(t, err)
+	})
+}
+
+func TestOCIChartRef(t *testing.T) {
+	assert.Equal(t, "example.com/charts/foo", ociChartRef("oci://example.com/charts/foo", ""))
+	assert.Equal(t, "example.com/charts/foo:1.2.3", ociChartRef("oci://example.com/charts/foo", "1.2.3"))
+}
+
+func TestVerifyOCIChartProvenance(t *testing.T) {
+	registryClient, err := registry.NewClient()
+	require.NoError(t, err)
+
+	t.Run("wraps the pull error when the chart can't be reached", func(t *testing.T) {
+		err := verifyOCIChartProvenance(registryClient, "oci://127.0.0.1:1/charts/foo", "1.2.3", t.TempDir()+"/foo.tgz", "keyring.gpg")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't pull provenance for `oci://127.0.0.1:1/charts/foo`")
+	})
+}